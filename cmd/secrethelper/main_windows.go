@@ -0,0 +1,83 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2018 Datadog, Inc.
+
+// +build windows
+
+// Command secrethelper is the datadog-secret-helper Windows service: a
+// long-lived process running as datadog_secretuser that resolves secrets on
+// behalf of the main agent over a named pipe, so the agent doesn't pay for
+// CreateProcessWithLogonW on every secret lookup.
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"golang.org/x/sys/windows/svc"
+
+	"github.com/DataDog/datadog-agent/pkg/secrets"
+	"github.com/DataDog/datadog-agent/pkg/util/log"
+)
+
+const serviceName = "datadog-secret-helper"
+
+type helperService struct{}
+
+func (s *helperService) Execute(args []string, r <-chan svc.ChangeRequest, changes chan<- svc.Status) (bool, uint32) {
+	changes <- svc.Status{State: svc.StartPending}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	errch := make(chan error, 1)
+	go func() { errch <- secrets.RunHelperServer(ctx) }()
+
+	changes <- svc.Status{State: svc.Running, Accepts: svc.AcceptStop | svc.AcceptShutdown}
+
+	for {
+		select {
+		case err := <-errch:
+			if err != nil {
+				log.Errorf("secret helper stopped: %s", err)
+			}
+			changes <- svc.Status{State: svc.StopPending}
+			return false, 1
+		case req := <-r:
+			switch req.Cmd {
+			case svc.Stop, svc.Shutdown:
+				changes <- svc.Status{State: svc.StopPending}
+				cancel()
+				<-errch
+				return false, 0
+			case svc.Interrogate:
+				changes <- req.CurrentStatus
+			}
+		}
+	}
+}
+
+func main() {
+	isService, err := svc.IsWindowsService()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "could not determine if running as a Windows service: %s\n", err)
+		os.Exit(1)
+	}
+
+	if !isService {
+		// Allow running interactively (e.g. for manual troubleshooting)
+		// without going through the service control manager.
+		if err := secrets.RunHelperServer(context.Background()); err != nil {
+			fmt.Fprintf(os.Stderr, "%s\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	if err := svc.Run(serviceName, &helperService{}); err != nil {
+		log.Errorf("secret helper service failed: %s", err)
+		os.Exit(1)
+	}
+}
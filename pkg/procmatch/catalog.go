@@ -0,0 +1,129 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2018 Datadog, Inc.
+
+package procmatch
+
+//go:generate go run gen/generate_catalog.go
+
+import (
+	"bytes"
+	"compress/gzip"
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// catalogSchemaVersion is bumped whenever CatalogBundle's shape changes in a
+// way that isn't backward compatible, so LoadCatalog can reject a bundle it
+// doesn't know how to read instead of silently misinterpreting it.
+const catalogSchemaVersion = 1
+
+// Integration describes a single integration's process-matching signatures,
+// as scraped from its manifest.json.
+type Integration struct {
+	Name       string   `json:"name"`
+	Signatures []string `json:"process_signatures"`
+}
+
+// IntegrationCatalog is an ordered collection of integrations to match
+// processes against.
+type IntegrationCatalog []Integration
+
+// CatalogBundle is the versioned, embeddable unit produced by `go generate`
+// and consumed by LoadCatalog. Shipping the version alongside the
+// integrations lets LoadCatalog reject a catalog it doesn't understand,
+// whether it's the one embedded in the binary or one pulled in out-of-band
+// (e.g. over remote config).
+type CatalogBundle struct {
+	Version      int                `json:"version"`
+	Integrations IntegrationCatalog `json:"integrations"`
+}
+
+//go:embed catalog.json.gz
+var embeddedCatalog []byte
+
+// DefaultCatalog is the catalog shipped with the agent binary, decoded once
+// at init from the gzip-compressed JSON embedded by `go generate`. It
+// replaces the previous generated default_catalog.go, which baked the
+// catalog in as Go source rather than data, forcing a recompile for every
+// catalog update.
+var DefaultCatalog IntegrationCatalog
+
+func init() {
+	bundle, err := LoadCatalog(bytes.NewReader(embeddedCatalog))
+	if err != nil {
+		panic(fmt.Sprintf("embedded process signature catalog is invalid: %s", err))
+	}
+	DefaultCatalog = bundle.Integrations
+}
+
+// LoadCatalog reads a gzip-compressed, JSON-encoded CatalogBundle. Callers
+// use it to load the catalog embedded in the binary, or to load a catalog
+// shipped separately (a user-supplied file, or one pulled out-of-band from a
+// remote config channel) without recompiling the agent.
+func LoadCatalog(r io.Reader) (CatalogBundle, error) {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return CatalogBundle{}, fmt.Errorf("could not decompress process signature catalog: %s", err)
+	}
+	defer gz.Close()
+
+	var bundle CatalogBundle
+	if err := json.NewDecoder(gz).Decode(&bundle); err != nil {
+		return CatalogBundle{}, fmt.Errorf("could not decode process signature catalog: %s", err)
+	}
+
+	if err := validateCatalog(bundle); err != nil {
+		return CatalogBundle{}, err
+	}
+	return bundle, nil
+}
+
+// validateCatalog rejects a bundle with an unsupported schema version or
+// malformed entries, so a bad catalog update fails loudly at load time
+// rather than producing silent false-negative process matches.
+func validateCatalog(bundle CatalogBundle) error {
+	if bundle.Version != catalogSchemaVersion {
+		return fmt.Errorf("unsupported process signature catalog version %d, expected %d", bundle.Version, catalogSchemaVersion)
+	}
+	for i, integration := range bundle.Integrations {
+		if integration.Name == "" {
+			return fmt.Errorf("catalog entry %d is missing a name", i)
+		}
+		if len(integration.Signatures) == 0 {
+			return fmt.Errorf("catalog entry %q has no process signatures", integration.Name)
+		}
+	}
+	return nil
+}
+
+// MergeCatalogs layers overlay on top of base: an integration present in
+// both is taken from overlay. This lets a user catalog override or extend
+// the default catalog's entries without having to restate it in full,
+// analogous to how container tooling layers config from multiple sources.
+func MergeCatalogs(base, overlay IntegrationCatalog) IntegrationCatalog {
+	merged := make(map[string]Integration, len(base)+len(overlay))
+	order := make([]string, 0, len(base)+len(overlay))
+
+	for _, integration := range base {
+		if _, found := merged[integration.Name]; !found {
+			order = append(order, integration.Name)
+		}
+		merged[integration.Name] = integration
+	}
+	for _, integration := range overlay {
+		if _, found := merged[integration.Name]; !found {
+			order = append(order, integration.Name)
+		}
+		merged[integration.Name] = integration
+	}
+
+	result := make(IntegrationCatalog, 0, len(order))
+	for _, name := range order {
+		result = append(result, merged[name])
+	}
+	return result
+}
@@ -0,0 +1,139 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2018 Datadog, Inc.
+
+package procmatch
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func gzipBundle(t *testing.T, bundle CatalogBundle) *bytes.Buffer {
+	t.Helper()
+
+	encoded, err := json.Marshal(bundle)
+	require.NoError(t, err)
+
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	_, err = gz.Write(encoded)
+	require.NoError(t, err)
+	require.NoError(t, gz.Close())
+	return &buf
+}
+
+func TestLoadCatalogRoundTrip(t *testing.T) {
+	bundle := CatalogBundle{
+		Version: catalogSchemaVersion,
+		Integrations: IntegrationCatalog{
+			{Name: "redisdb", Signatures: []string{"redis-server"}},
+		},
+	}
+
+	loaded, err := LoadCatalog(gzipBundle(t, bundle))
+	require.NoError(t, err)
+	assert.Equal(t, bundle, loaded)
+}
+
+func TestLoadCatalogRejectsUnsupportedVersion(t *testing.T) {
+	bundle := CatalogBundle{
+		Version: catalogSchemaVersion + 1,
+		Integrations: IntegrationCatalog{
+			{Name: "redisdb", Signatures: []string{"redis-server"}},
+		},
+	}
+
+	_, err := LoadCatalog(gzipBundle(t, bundle))
+	assert.Error(t, err)
+}
+
+func TestLoadCatalogRejectsMalformedEntries(t *testing.T) {
+	cases := []struct {
+		name   string
+		bundle CatalogBundle
+	}{
+		{
+			name: "missing name",
+			bundle: CatalogBundle{
+				Version:      catalogSchemaVersion,
+				Integrations: IntegrationCatalog{{Signatures: []string{"redis-server"}}},
+			},
+		},
+		{
+			name: "missing signatures",
+			bundle: CatalogBundle{
+				Version:      catalogSchemaVersion,
+				Integrations: IntegrationCatalog{{Name: "redisdb"}},
+			},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			_, err := LoadCatalog(gzipBundle(t, c.bundle))
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestLoadCatalogRejectsNonGzipInput(t *testing.T) {
+	_, err := LoadCatalog(bytes.NewReader([]byte("not gzip data")))
+	assert.Error(t, err)
+}
+
+func TestMergeCatalogsOverlayWins(t *testing.T) {
+	base := IntegrationCatalog{
+		{Name: "redisdb", Signatures: []string{"redis-server"}},
+		{Name: "postgres", Signatures: []string{"postgres"}},
+	}
+	overlay := IntegrationCatalog{
+		{Name: "redisdb", Signatures: []string{"redis-server", "redis-sentinel"}},
+		{Name: "custom-app", Signatures: []string{"my-custom-app"}},
+	}
+
+	merged := MergeCatalogs(base, overlay)
+
+	byName := make(map[string]Integration, len(merged))
+	for _, integration := range merged {
+		byName[integration.Name] = integration
+	}
+
+	require.Len(t, merged, 3)
+	assert.Equal(t, []string{"redis-server", "redis-sentinel"}, byName["redisdb"].Signatures, "overlay entry should win")
+	assert.Equal(t, []string{"postgres"}, byName["postgres"].Signatures, "base-only entry should survive untouched")
+	assert.Equal(t, []string{"my-custom-app"}, byName["custom-app"].Signatures, "overlay-only entry should be added")
+}
+
+func TestMergeCatalogsPreservesFirstSeenOrder(t *testing.T) {
+	base := IntegrationCatalog{
+		{Name: "a", Signatures: []string{"a"}},
+		{Name: "b", Signatures: []string{"b"}},
+	}
+	overlay := IntegrationCatalog{
+		{Name: "b", Signatures: []string{"b2"}},
+		{Name: "c", Signatures: []string{"c"}},
+	}
+
+	merged := MergeCatalogs(base, overlay)
+
+	names := make([]string, len(merged))
+	for i, integration := range merged {
+		names[i] = integration.Name
+	}
+	assert.Equal(t, []string{"a", "b", "c"}, names)
+}
+
+func TestDefaultCatalogLoadsAndValidates(t *testing.T) {
+	require.NotEmpty(t, DefaultCatalog, "the embedded catalog should decode into at least one integration")
+	for _, integration := range DefaultCatalog {
+		assert.NotEmpty(t, integration.Name)
+		assert.NotEmpty(t, integration.Signatures)
+	}
+}
@@ -3,35 +3,39 @@
 package main
 
 import (
-	"bytes"
+	"compress/gzip"
 	"encoding/json"
 	"fmt"
-	"html/template"
 	"io/ioutil"
 	"os"
 	"path"
 )
 
-type Manifest struct {
+// manifest mirrors the subset of an integration's manifest.json this
+// generator cares about.
+type manifest struct {
 	Signatures []string `json:"process_signatures"`
 	Name       *string  `json:"name"`
 }
 
-const codeTemplate = `// Code generated by go generate; DO NOT EDIT.
-package procmatch
+// integration and catalogBundle mirror pkg/procmatch.Integration and
+// pkg/procmatch.CatalogBundle. They're redeclared here, rather than
+// imported, because this file builds with the `ignore` tag and runs via `go
+// generate` before the rest of the package is necessarily buildable.
+type integration struct {
+	Name       string   `json:"name"`
+	Signatures []string `json:"process_signatures"`
+}
 
-var DefaultCatalog IntegrationCatalog = IntegrationCatalog{ {{ range $_, $manifest := . }}
-	Integration{
-		Name: "{{$manifest.Name}}",
-		Signatures: []string{ {{ range $_, $sig := $manifest.Signatures }}
-			"{{$sig}}",{{end}}
-		},
-	},{{ end }}
+type catalogBundle struct {
+	Version      int           `json:"version"`
+	Integrations []integration `json:"integrations"`
 }
-`
 
-func readManifest(raw []byte) (Manifest, bool) {
-	m := Manifest{}
+const catalogSchemaVersion = 1
+
+func readManifest(raw []byte) (manifest, bool) {
+	m := manifest{}
 	_ = json.Unmarshal(raw, &m)
 	return m, m.Signatures != nil && m.Name != nil
 }
@@ -54,30 +58,33 @@ func main() {
 	dirs, err := ioutil.ReadDir(rootDir)
 	failIf(err, "An error occured listing directories in %s: %s", rootDir, err)
 
-	manifests := []Manifest{}
+	bundle := catalogBundle{Version: catalogSchemaVersion}
 
 	for _, dir := range dirs {
 		if dir.IsDir() {
 			// Ignore errors
-			manifest, _ := ioutil.ReadFile(path.Join(rootDir, dir.Name(), "manifest.json"))
-			decoded, ok := readManifest(manifest)
+			raw, _ := ioutil.ReadFile(path.Join(rootDir, dir.Name(), "manifest.json"))
+			decoded, ok := readManifest(raw)
 			if ok {
-				manifests = append(manifests, decoded)
+				bundle.Integrations = append(bundle.Integrations, integration{
+					Name:       *decoded.Name,
+					Signatures: decoded.Signatures,
+				})
 			}
 		}
 	}
 
-	tmpl := template.New("catalog")
-	tmpl, err = tmpl.Parse(codeTemplate)
-	failIf(err, "Couldn't parse code template: %s", err)
-
-	var buf bytes.Buffer
+	encoded, err := json.Marshal(bundle)
+	failIf(err, "Couldn't encode catalog bundle: %s", err)
 
-	err = tmpl.Execute(&buf, manifests)
-	failIf(err, "Couldn't execute template: %s", err)
+	out, err := os.Create("./catalog.json.gz")
+	failIf(err, "Couldn't create catalog.json.gz: %s", err)
+	defer out.Close()
 
-	err = ioutil.WriteFile("./default_catalog.go", buf.Bytes(), 0644)
-	failIf(err, "Couldn't write file to disk: %s", err)
+	gz := gzip.NewWriter(out)
+	_, err = gz.Write(encoded)
+	failIf(err, "Couldn't write compressed catalog: %s", err)
+	failIf(gz.Close(), "Couldn't flush compressed catalog: %s", err)
 
-	fmt.Printf("%v entries generated !\n", len(manifests))
+	fmt.Printf("%v entries generated !\n", len(bundle.Integrations))
 }
@@ -0,0 +1,186 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2018 Datadog, Inc.
+
+package secrets
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+)
+
+// backendTypeExecCommand is the historical (and still default) backend: it
+// shells out to secretBackendCommand exactly as before. It is kept as its
+// own type so that 'secret_backend_type' can default to it without users
+// having to change any configuration.
+const backendTypeExecCommand = "exec"
+
+// SecretVal is the value returned by a backend for a single handle. It
+// mirrors the `{handle: {value, error}}` schema produced by the exec
+// backend's stdout payload so every backend can be consumed the same way
+// regardless of transport.
+type SecretVal struct {
+	Value string `json:"value"`
+	Error string `json:"error"`
+	// TTL optionally overrides the cache's default TTL for this handle, in
+	// seconds. Zero means "use the default TTL".
+	TTL int `json:"ttl,omitempty"`
+}
+
+// SecretBackend resolves a batch of secret handles to their values. Unlike
+// execCommand, which is wired directly into the subprocess transport,
+// implementations of this interface are free to use any transport (HTTP,
+// gRPC, Vault's API, ...) as long as they can resolve a list of handles in
+// one round trip.
+type SecretBackend interface {
+	// Name identifies the backend for logging and error messages.
+	Name() string
+	// Resolve fetches the values for the given handles. The returned map
+	// is keyed by handle; a handle missing from the map is treated as an
+	// unresolved error by the caller.
+	Resolve(handles []string) (map[string]SecretVal, error)
+}
+
+// BackendFactory builds a SecretBackend from the agent configuration.
+type BackendFactory func() (SecretBackend, error)
+
+var (
+	backendFactoriesMu sync.Mutex
+	backendFactories   = map[string]BackendFactory{}
+)
+
+// RegisterSecretBackend makes a backend implementation available under
+// backendType so it can be selected via the 'secret_backend_type' config
+// option. Backend packages call this from an init() function.
+func RegisterSecretBackend(backendType string, factory BackendFactory) {
+	backendFactoriesMu.Lock()
+	defer backendFactoriesMu.Unlock()
+	backendFactories[backendType] = factory
+}
+
+// NewSecretBackend instantiates the backend registered under backendType,
+// wrapped in the TTL cache so every transport benefits from the same
+// memoization and background refresh behavior.
+func NewSecretBackend(backendType string) (SecretBackend, error) {
+	backendFactoriesMu.Lock()
+	factory, found := backendFactories[backendType]
+	backendFactoriesMu.Unlock()
+	if !found {
+		return nil, fmt.Errorf("unknown secret_backend_type '%s'", backendType)
+	}
+
+	backend, err := factory()
+	if err != nil {
+		return nil, err
+	}
+	return newCachingBackend(backend), nil
+}
+
+func init() {
+	RegisterSecretBackend(backendTypeExecCommand, func() (SecretBackend, error) {
+		return &execCommandBackend{}, nil
+	})
+}
+
+var (
+	activeBackendMu sync.Mutex
+	activeBackend   *cachingBackend
+)
+
+// backend lazily builds (and memoizes) the SecretBackend selected by
+// secretBackendType, so config reload paths share the same cache instead of
+// each spinning up their own.
+func backend() (*cachingBackend, error) {
+	activeBackendMu.Lock()
+	defer activeBackendMu.Unlock()
+
+	if activeBackend != nil {
+		return activeBackend, nil
+	}
+
+	b, err := NewSecretBackend(secretBackendType)
+	if err != nil {
+		return nil, err
+	}
+
+	activeBackend = b.(*cachingBackend)
+	return activeBackend, nil
+}
+
+// FlushCache empties the secrets cache, forcing every handle to be
+// re-resolved against the backend on its next use. Callers wire it up
+// behind a CLI subcommand or admin HTTP route as needed; see
+// FlushCacheHandler for a ready-made handler for the latter.
+func FlushCache() {
+	activeBackendMu.Lock()
+	b := activeBackend
+	activeBackendMu.Unlock()
+
+	if b != nil {
+		b.Flush()
+	}
+}
+
+// FlushCacheHandler is an http.HandlerFunc that calls FlushCache, meant to
+// be mounted on the agent's admin/IPC server (e.g. as
+// "POST /agent/secrets/refresh") so the cache can be flushed without
+// restarting the agent.
+func FlushCacheHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	FlushCache()
+	w.WriteHeader(http.StatusOK)
+}
+
+// execCommandBackend adapts the legacy execCommand subprocess transport to
+// the SecretBackend interface so it keeps working as just one of several
+// pluggable backends.
+type execCommandBackend struct{}
+
+func (b *execCommandBackend) Name() string { return backendTypeExecCommand }
+
+func (b *execCommandBackend) Resolve(handles []string) (map[string]SecretVal, error) {
+	payload, err := buildSecretRequestPayload(handles)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := execCommand(payload)
+	if err != nil {
+		return nil, fmt.Errorf("error while running '%s': %s", secretBackendCommand, err)
+	}
+
+	return unmarshalSecretResponse(resp)
+}
+
+// buildSecretRequestPayload encodes the handles to resolve using the
+// standard `{"version": "1.0", "secrets": [...]}` request schema shared by
+// every backend transport.
+func buildSecretRequestPayload(handles []string) (string, error) {
+	payload, err := json.Marshal(struct {
+		Version string   `json:"version"`
+		Secrets []string `json:"secrets"`
+	}{
+		Version: "1.0",
+		Secrets: handles,
+	})
+	if err != nil {
+		return "", fmt.Errorf("could not serialize secrets request payload: %s", err)
+	}
+	return string(payload), nil
+}
+
+// unmarshalSecretResponse decodes the common `{handle: {value, error}}`
+// response schema returned by every backend transport.
+func unmarshalSecretResponse(data []byte) (map[string]SecretVal, error) {
+	vals := map[string]SecretVal{}
+	if err := json.Unmarshal(data, &vals); err != nil {
+		return nil, fmt.Errorf("could not unmarshal secret backend response: %s", err)
+	}
+	return vals, nil
+}
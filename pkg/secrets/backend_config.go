@@ -0,0 +1,47 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2018 Datadog, Inc.
+
+package secrets
+
+// Package-level configuration for the non-exec secret backends. These are
+// populated from the 'secret_backend_type', 'secret_backend_url',
+// 'secret_backend_grpc_*' and 'secret_backend_vault_*' config options
+// alongside the existing secretBackendCommand/secretBackendArguments pair.
+var (
+	// secretBackendType selects which SecretBackend implementation to use.
+	// It defaults to backendTypeExecCommand so existing 'secret_backend_command'
+	// based configurations keep working unchanged.
+	secretBackendType = backendTypeExecCommand
+
+	// secretBackendURL is the endpoint used by the 'http' backend.
+	secretBackendURL string
+
+	// secretBackendGRPCAddress is the `host:port` dialed by the 'grpc' backend.
+	secretBackendGRPCAddress string
+
+	// secretBackendGRPCInsecure disables TLS when dialing secretBackendGRPCAddress.
+	// It should only be set for local/loopback endpoints.
+	secretBackendGRPCInsecure bool
+
+	// secretBackendVaultAddress is the Vault server address, e.g. "https://vault:8200".
+	secretBackendVaultAddress string
+
+	// secretBackendVaultPathTemplate is a text/template string rendered with
+	// the handle to produce the Vault path to read, e.g.
+	// "secret/data/datadog/{{.Handle}}".
+	secretBackendVaultPathTemplate string
+
+	// secretBackendVaultKVVersion is either 1 or 2 and picks the KV engine
+	// response shape to parse.
+	secretBackendVaultKVVersion = 2
+
+	// secretBackendVaultToken authenticates directly against Vault when set.
+	secretBackendVaultToken string
+
+	// secretBackendVaultRoleID and secretBackendVaultSecretID authenticate via
+	// Vault's AppRole auth method when secretBackendVaultToken is empty.
+	secretBackendVaultRoleID   string
+	secretBackendVaultSecretID string
+)
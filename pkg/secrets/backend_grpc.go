@@ -0,0 +1,81 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2018 Datadog, Inc.
+
+package secrets
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/DataDog/datadog-agent/pkg/proto/pbgo"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+)
+
+const backendTypeGRPC = "grpc"
+
+func init() {
+	RegisterSecretBackend(backendTypeGRPC, func() (SecretBackend, error) {
+		return newGRPCBackend(secretBackendGRPCAddress, secretBackendGRPCInsecure, secretBackendTimeout)
+	})
+}
+
+// grpcBackend resolves handles against a user-run implementation of the
+// SecretBackend gRPC service defined in secrets.proto, in a single Resolve
+// call per batch of handles instead of one subprocess per handle.
+type grpcBackend struct {
+	address string
+	conn    *grpc.ClientConn
+	client  pbgo.SecretBackendClient
+	timeout time.Duration
+}
+
+func newGRPCBackend(address string, insecureConn bool, timeoutSeconds int) (*grpcBackend, error) {
+	if address == "" {
+		return nil, fmt.Errorf("secret_backend_grpc_address must be set to use the '%s' secret backend", backendTypeGRPC)
+	}
+
+	opts := []grpc.DialOption{grpc.WithBlock()}
+	if insecureConn {
+		opts = append(opts, grpc.WithInsecure())
+	} else {
+		opts = append(opts, grpc.WithTransportCredentials(credentials.NewTLS(nil)))
+	}
+
+	timeout := time.Duration(timeoutSeconds) * time.Second
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	conn, err := grpc.DialContext(ctx, address, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("could not dial secret backend gRPC service at '%s': %s", address, err)
+	}
+
+	return &grpcBackend{
+		address: address,
+		conn:    conn,
+		client:  pbgo.NewSecretBackendClient(conn),
+		timeout: timeout,
+	}, nil
+}
+
+func (b *grpcBackend) Name() string { return backendTypeGRPC }
+
+func (b *grpcBackend) Resolve(handles []string) (map[string]SecretVal, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), b.timeout)
+	defer cancel()
+
+	resp, err := b.client.Resolve(ctx, &pbgo.ResolveRequest{Handles: handles})
+	if err != nil {
+		return nil, fmt.Errorf("error while calling secret backend gRPC service at '%s': %s", b.address, err)
+	}
+
+	vals := make(map[string]SecretVal, len(resp.GetValues()))
+	for handle, v := range resp.GetValues() {
+		vals[handle] = SecretVal{Value: v.Value, Error: v.Error, TTL: int(v.Ttl)}
+	}
+	return vals, nil
+}
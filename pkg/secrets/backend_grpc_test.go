@@ -0,0 +1,68 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2018 Datadog, Inc.
+
+package secrets
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"github.com/DataDog/datadog-agent/pkg/proto/pbgo"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+)
+
+// fakeSecretBackendServer implements pbgo.SecretBackendServer to drive
+// grpcBackend against a real (loopback) gRPC connection instead of mocking
+// the generated client.
+type fakeSecretBackendServer struct {
+	values map[string]*pbgo.SecretValue
+}
+
+func (s *fakeSecretBackendServer) Resolve(ctx context.Context, req *pbgo.ResolveRequest) (*pbgo.ResolveResponse, error) {
+	values := make(map[string]*pbgo.SecretValue, len(req.Handles))
+	for _, h := range req.Handles {
+		if v, ok := s.values[h]; ok {
+			values[h] = v
+		}
+	}
+	return &pbgo.ResolveResponse{Values: values}, nil
+}
+
+func startFakeSecretBackendServer(t *testing.T, values map[string]*pbgo.SecretValue) string {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	srv := grpc.NewServer()
+	pbgo.RegisterSecretBackendServer(srv, &fakeSecretBackendServer{values: values})
+
+	go func() { _ = srv.Serve(lis) }()
+	t.Cleanup(srv.Stop)
+
+	return lis.Addr().String()
+}
+
+func TestGRPCBackendResolve(t *testing.T) {
+	addr := startFakeSecretBackendServer(t, map[string]*pbgo.SecretValue{
+		"handle1": {Value: "value1", Ttl: 60},
+		"handle2": {Error: "denied"},
+	})
+
+	b, err := newGRPCBackend(addr, true, 5)
+	require.NoError(t, err)
+	defer b.conn.Close()
+
+	vals, err := b.Resolve([]string{"handle1", "handle2"})
+	require.NoError(t, err)
+	assert.Equal(t, SecretVal{Value: "value1", TTL: 60}, vals["handle1"])
+	assert.Equal(t, SecretVal{Error: "denied"}, vals["handle2"])
+}
+
+func TestNewGRPCBackendRequiresAddress(t *testing.T) {
+	_, err := newGRPCBackend("", true, 5)
+	require.Error(t, err)
+}
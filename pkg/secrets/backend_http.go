@@ -0,0 +1,76 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2018 Datadog, Inc.
+
+package secrets
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+const backendTypeHTTP = "http"
+
+func init() {
+	RegisterSecretBackend(backendTypeHTTP, func() (SecretBackend, error) {
+		return newHTTPBackend(secretBackendURL, secretBackendTimeout)
+	})
+}
+
+// httpBackend resolves handles against a user-provided HTTP(S) endpoint. It
+// POSTs the same request payload the exec backend builds and expects a
+// response following the common `{handle: {value, error}}` schema, which
+// lets operators integrate any secret store that can be fronted by a small
+// HTTP service without wrapping it in a shell script.
+type httpBackend struct {
+	url    string
+	client *http.Client
+}
+
+func newHTTPBackend(url string, timeoutSeconds int) (*httpBackend, error) {
+	if url == "" {
+		return nil, fmt.Errorf("secret_backend_url must be set to use the '%s' secret backend", backendTypeHTTP)
+	}
+	return &httpBackend{
+		url: url,
+		client: &http.Client{
+			Timeout: time.Duration(timeoutSeconds) * time.Second,
+		},
+	}, nil
+}
+
+func (b *httpBackend) Name() string { return backendTypeHTTP }
+
+func (b *httpBackend) Resolve(handles []string) (map[string]SecretVal, error) {
+	payload, err := buildSecretRequestPayload(handles)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, b.url, bytes.NewBufferString(payload))
+	if err != nil {
+		return nil, fmt.Errorf("could not build request for secret backend '%s': %s", b.url, err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := b.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("error while calling secret backend '%s': %s", b.url, err)
+	}
+	defer resp.Body.Close()
+
+	body := limitBuffer{buf: &bytes.Buffer{}, max: secretBackendOutputMaxSize}
+	if _, err := io.Copy(&body, resp.Body); err != nil {
+		return nil, fmt.Errorf("error while reading secret backend '%s' response: %s", b.url, err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("secret backend '%s' returned status %s", b.url, resp.Status)
+	}
+
+	return unmarshalSecretResponse(body.buf.Bytes())
+}
@@ -0,0 +1,73 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2018 Datadog, Inc.
+
+package secrets
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHTTPBackendResolve(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodPost, r.Method)
+		assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+
+		var req struct {
+			Version string   `json:"version"`
+			Secrets []string `json:"secrets"`
+		}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		assert.Equal(t, []string{"handle1"}, req.Secrets)
+
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"handle1":{"value":"value1"}}`))
+	}))
+	defer srv.Close()
+
+	b, err := newHTTPBackend(srv.URL, 5)
+	require.NoError(t, err)
+
+	vals, err := b.Resolve([]string{"handle1"})
+	require.NoError(t, err)
+	assert.Equal(t, "value1", vals["handle1"].Value)
+}
+
+func TestHTTPBackendResolveNonOKStatus(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer srv.Close()
+
+	b, err := newHTTPBackend(srv.URL, 5)
+	require.NoError(t, err)
+
+	_, err = b.Resolve([]string{"handle1"})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "500")
+}
+
+func TestHTTPBackendResolveMalformedResponse(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("not json"))
+	}))
+	defer srv.Close()
+
+	b, err := newHTTPBackend(srv.URL, 5)
+	require.NoError(t, err)
+
+	_, err = b.Resolve([]string{"handle1"})
+	require.Error(t, err)
+}
+
+func TestNewHTTPBackendRequiresURL(t *testing.T) {
+	_, err := newHTTPBackend("", 5)
+	require.Error(t, err)
+}
@@ -0,0 +1,68 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2018 Datadog, Inc.
+
+package secrets
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewSecretBackendUnknownType(t *testing.T) {
+	_, err := NewSecretBackend("does-not-exist")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "does-not-exist")
+}
+
+func TestNewSecretBackendWrapsFactoryInCache(t *testing.T) {
+	const backendType = "test-fake"
+	fake := newFakeBackend(map[string]SecretVal{"handle1": {Value: "value1"}})
+	RegisterSecretBackend(backendType, func() (SecretBackend, error) {
+		return fake, nil
+	})
+
+	b, err := NewSecretBackend(backendType)
+	require.NoError(t, err)
+
+	cache, ok := b.(*cachingBackend)
+	require.True(t, ok, "NewSecretBackend must wrap every backend in the TTL cache")
+
+	vals, err := cache.Resolve([]string{"handle1"})
+	require.NoError(t, err)
+	assert.Equal(t, "value1", vals["handle1"].Value)
+}
+
+func TestNewSecretBackendPropagatesFactoryError(t *testing.T) {
+	const backendType = "test-fake-error"
+	RegisterSecretBackend(backendType, func() (SecretBackend, error) {
+		return nil, fmt.Errorf("boom")
+	})
+
+	_, err := NewSecretBackend(backendType)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "boom")
+}
+
+func TestBuildSecretRequestPayload(t *testing.T) {
+	payload, err := buildSecretRequestPayload([]string{"handle1", "handle2"})
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"version":"1.0","secrets":["handle1","handle2"]}`, payload)
+}
+
+func TestUnmarshalSecretResponse(t *testing.T) {
+	vals, err := unmarshalSecretResponse([]byte(`{"handle1":{"value":"value1"},"handle2":{"error":"denied","ttl":60}}`))
+	require.NoError(t, err)
+	assert.Equal(t, "value1", vals["handle1"].Value)
+	assert.Equal(t, "denied", vals["handle2"].Error)
+	assert.Equal(t, 60, vals["handle2"].TTL)
+}
+
+func TestUnmarshalSecretResponseInvalidJSON(t *testing.T) {
+	_, err := unmarshalSecretResponse([]byte("not json"))
+	require.Error(t, err)
+}
@@ -0,0 +1,259 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2018 Datadog, Inc.
+
+package secrets
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+)
+
+const backendTypeVault = "vault"
+
+func init() {
+	RegisterSecretBackend(backendTypeVault, func() (SecretBackend, error) {
+		return newVaultBackend(
+			secretBackendVaultAddress,
+			secretBackendVaultPathTemplate,
+			secretBackendVaultKVVersion,
+			secretBackendTimeout,
+		)
+	})
+}
+
+// vaultBackend resolves handles by reading them out of a HashiCorp Vault
+// KV engine (v1 or v2). The handle is rendered through
+// secretBackendVaultPathTemplate to build the path to read, which lets a
+// single backend serve handles spread across several Vault paths/mounts.
+//
+// vaultBackend lives for the lifetime of the agent process (it's wrapped by
+// the package-wide cachingBackend singleton), so its token can't just be
+// fetched once at construction time: AppRole-issued tokens expire, commonly
+// within an hour, and every resolveOne re-authenticates on a 403 to pick up
+// a fresh one instead of failing forever until the agent is restarted.
+type vaultBackend struct {
+	address   string
+	pathTmpl  *template.Template
+	kvVersion int
+	client    *http.Client
+
+	mu    sync.Mutex
+	token string
+}
+
+func newVaultBackend(address, pathTemplate string, kvVersion, timeoutSeconds int) (*vaultBackend, error) {
+	if address == "" {
+		return nil, fmt.Errorf("secret_backend_vault_address must be set to use the '%s' secret backend", backendTypeVault)
+	}
+	if pathTemplate == "" {
+		return nil, fmt.Errorf("secret_backend_vault_path_template must be set to use the '%s' secret backend", backendTypeVault)
+	}
+	if kvVersion != 1 && kvVersion != 2 {
+		return nil, fmt.Errorf("secret_backend_vault_kv_version must be 1 or 2, got %d", kvVersion)
+	}
+
+	tmpl, err := template.New("vault-path").Parse(pathTemplate)
+	if err != nil {
+		return nil, fmt.Errorf("could not parse secret_backend_vault_path_template: %s", err)
+	}
+
+	token, err := vaultAuthenticate(address, time.Duration(timeoutSeconds)*time.Second)
+	if err != nil {
+		return nil, err
+	}
+
+	return &vaultBackend{
+		address:   strings.TrimRight(address, "/"),
+		pathTmpl:  tmpl,
+		kvVersion: kvVersion,
+		client:    &http.Client{Timeout: time.Duration(timeoutSeconds) * time.Second},
+		token:     token,
+	}, nil
+}
+
+func (b *vaultBackend) Name() string { return backendTypeVault }
+
+func (b *vaultBackend) Resolve(handles []string) (map[string]SecretVal, error) {
+	vals := map[string]SecretVal{}
+	for _, handle := range handles {
+		value, err := b.resolveOne(handle)
+		if err != nil {
+			vals[handle] = SecretVal{Error: err.Error()}
+			continue
+		}
+		vals[handle] = SecretVal{Value: value}
+	}
+	return vals, nil
+}
+
+func (b *vaultBackend) resolveOne(handle string) (string, error) {
+	path, err := b.renderPath(handle)
+	if err != nil {
+		return "", err
+	}
+
+	resp, err := b.doAuthenticatedRequest(path)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	var raw struct {
+		Data map[string]interface{} `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&raw); err != nil {
+		return "", fmt.Errorf("could not decode Vault response for path '%s': %s", path, err)
+	}
+
+	data := raw.Data
+	if b.kvVersion == 2 {
+		// KV v2 nests the actual secret one level deeper, under "data".
+		nested, ok := raw.Data["data"].(map[string]interface{})
+		if !ok {
+			return "", fmt.Errorf("unexpected KV v2 response shape for path '%s'", path)
+		}
+		data = nested
+	}
+
+	value, ok := data["value"]
+	if !ok {
+		return "", fmt.Errorf("Vault secret at '%s' has no 'value' key", path)
+	}
+	str, ok := value.(string)
+	if !ok {
+		return "", fmt.Errorf("Vault secret at '%s' 'value' key is not a string", path)
+	}
+	return str, nil
+}
+
+// doAuthenticatedRequest reads path off Vault with the backend's current
+// token, re-authenticating and retrying exactly once if the token has
+// expired or been revoked (a 403 "permission denied" response). The caller
+// is responsible for closing the returned response's body.
+func (b *vaultBackend) doAuthenticatedRequest(path string) (*http.Response, error) {
+	resp, err := b.doRequest(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode != http.StatusForbidden {
+		if resp.StatusCode != http.StatusOK {
+			resp.Body.Close()
+			return nil, fmt.Errorf("Vault returned status %s for path '%s'", resp.Status, path)
+		}
+		return resp, nil
+	}
+	resp.Body.Close()
+
+	if err := b.reauthenticate(); err != nil {
+		return nil, fmt.Errorf("Vault token rejected for path '%s' and could not re-authenticate: %s", path, err)
+	}
+
+	resp, err = b.doRequest(path)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("Vault returned status %s for path '%s' after re-authenticating", resp.Status, path)
+	}
+	return resp, nil
+}
+
+func (b *vaultBackend) doRequest(path string) (*http.Response, error) {
+	req, err := http.NewRequest(http.MethodGet, b.address+"/v1/"+path, nil)
+	if err != nil {
+		return nil, fmt.Errorf("could not build Vault request for path '%s': %s", path, err)
+	}
+
+	b.mu.Lock()
+	req.Header.Set("X-Vault-Token", b.token)
+	b.mu.Unlock()
+
+	resp, err := b.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("error while reading Vault path '%s': %s", path, err)
+	}
+	return resp, nil
+}
+
+// reauthenticate re-runs the backend's configured auth method (static
+// token or AppRole) and swaps in the resulting token, so a future request
+// picks up a fresh credential instead of retrying with one Vault just
+// rejected.
+func (b *vaultBackend) reauthenticate() error {
+	token, err := vaultAuthenticate(b.address, b.client.Timeout)
+	if err != nil {
+		return err
+	}
+
+	b.mu.Lock()
+	b.token = token
+	b.mu.Unlock()
+	return nil
+}
+
+func (b *vaultBackend) renderPath(handle string) (string, error) {
+	var buf bytes.Buffer
+	if err := b.pathTmpl.Execute(&buf, struct{ Handle string }{Handle: handle}); err != nil {
+		return "", fmt.Errorf("could not render Vault path for handle '%s': %s", handle, err)
+	}
+	return buf.String(), nil
+}
+
+// vaultAuthenticate logs into Vault using either a static token
+// (secretBackendVaultToken) or the AppRole auth method
+// (secretBackendVaultRoleID/secretBackendVaultSecretID) and returns the
+// client token to use for subsequent reads.
+func vaultAuthenticate(address string, timeout time.Duration) (string, error) {
+	if secretBackendVaultToken != "" {
+		return secretBackendVaultToken, nil
+	}
+
+	if secretBackendVaultRoleID == "" || secretBackendVaultSecretID == "" {
+		return "", fmt.Errorf("secret_backend_vault_token or secret_backend_vault_role_id/secret_id must be set")
+	}
+
+	payload, err := json.Marshal(struct {
+		RoleID   string `json:"role_id"`
+		SecretID string `json:"secret_id"`
+	}{
+		RoleID:   secretBackendVaultRoleID,
+		SecretID: secretBackendVaultSecretID,
+	})
+	if err != nil {
+		return "", fmt.Errorf("could not serialize Vault AppRole login payload: %s", err)
+	}
+
+	client := &http.Client{Timeout: timeout}
+	resp, err := client.Post(strings.TrimRight(address, "/")+"/v1/auth/approle/login", "application/json", bytes.NewReader(payload))
+	if err != nil {
+		return "", fmt.Errorf("error logging into Vault via AppRole: %s", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("Vault AppRole login returned status %s", resp.Status)
+	}
+
+	var auth struct {
+		Auth struct {
+			ClientToken string `json:"client_token"`
+		} `json:"auth"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&auth); err != nil {
+		return "", fmt.Errorf("could not decode Vault AppRole login response: %s", err)
+	}
+	if auth.Auth.ClientToken == "" {
+		return "", fmt.Errorf("Vault AppRole login response did not include a client token")
+	}
+	return auth.Auth.ClientToken, nil
+}
@@ -0,0 +1,121 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2018 Datadog, Inc.
+
+package secrets
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func withTestVaultToken(t *testing.T, token, roleID, secretID string) {
+	origToken, origRoleID, origSecretID := secretBackendVaultToken, secretBackendVaultRoleID, secretBackendVaultSecretID
+	secretBackendVaultToken, secretBackendVaultRoleID, secretBackendVaultSecretID = token, roleID, secretID
+	t.Cleanup(func() {
+		secretBackendVaultToken, secretBackendVaultRoleID, secretBackendVaultSecretID = origToken, origRoleID, origSecretID
+	})
+}
+
+func TestVaultBackendResolveKVv1(t *testing.T) {
+	withTestVaultToken(t, "static-token", "", "")
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/v1/secret/datadog/handle1", r.URL.Path)
+		assert.Equal(t, "static-token", r.Header.Get("X-Vault-Token"))
+		_, _ = w.Write([]byte(`{"data":{"value":"value1"}}`))
+	}))
+	defer srv.Close()
+
+	b, err := newVaultBackend(srv.URL, "secret/datadog/{{.Handle}}", 1, 5)
+	require.NoError(t, err)
+
+	vals, err := b.Resolve([]string{"handle1"})
+	require.NoError(t, err)
+	assert.Equal(t, "value1", vals["handle1"].Value)
+}
+
+func TestVaultBackendResolveKVv2(t *testing.T) {
+	withTestVaultToken(t, "static-token", "", "")
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/v1/secret/data/datadog/handle1", r.URL.Path)
+		_, _ = w.Write([]byte(`{"data":{"data":{"value":"value1"},"metadata":{"version":2}}}`))
+	}))
+	defer srv.Close()
+
+	b, err := newVaultBackend(srv.URL, "secret/data/datadog/{{.Handle}}", 2, 5)
+	require.NoError(t, err)
+
+	vals, err := b.Resolve([]string{"handle1"})
+	require.NoError(t, err)
+	assert.Equal(t, "value1", vals["handle1"].Value)
+}
+
+func TestVaultBackendResolveMissingValueKey(t *testing.T) {
+	withTestVaultToken(t, "static-token", "", "")
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"data":{"other":"nope"}}`))
+	}))
+	defer srv.Close()
+
+	b, err := newVaultBackend(srv.URL, "secret/datadog/{{.Handle}}", 1, 5)
+	require.NoError(t, err)
+
+	vals, err := b.Resolve([]string{"handle1"})
+	require.NoError(t, err, "Resolve surfaces per-handle errors rather than failing the whole batch")
+	assert.Contains(t, vals["handle1"].Error, "no 'value' key")
+}
+
+func TestVaultBackendReauthenticatesOn403(t *testing.T) {
+	withTestVaultToken(t, "", "role-id", "secret-id")
+
+	var loginCalls, readCalls int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v1/auth/approle/login":
+			loginCalls++
+			_, _ = w.Write([]byte(fmt.Sprintf(`{"auth":{"client_token":"token-%d"}}`, loginCalls)))
+		case "/v1/secret/datadog/handle1":
+			readCalls++
+			if r.Header.Get("X-Vault-Token") == "token-1" {
+				w.WriteHeader(http.StatusForbidden)
+				return
+			}
+			_, _ = w.Write([]byte(`{"data":{"value":"value1"}}`))
+		default:
+			t.Fatalf("unexpected request path %q", r.URL.Path)
+		}
+	}))
+	defer srv.Close()
+
+	b, err := newVaultBackend(srv.URL, "secret/datadog/{{.Handle}}", 1, 5)
+	require.NoError(t, err)
+	assert.Equal(t, 1, loginCalls, "construction should authenticate exactly once")
+
+	vals, err := b.Resolve([]string{"handle1"})
+	require.NoError(t, err)
+	assert.Equal(t, "value1", vals["handle1"].Value)
+	assert.Equal(t, 2, loginCalls, "a 403 must trigger exactly one re-authentication")
+	assert.Equal(t, 2, readCalls, "the read should be retried once after re-authenticating")
+}
+
+func TestNewVaultBackendValidatesConfig(t *testing.T) {
+	withTestVaultToken(t, "static-token", "", "")
+
+	_, err := newVaultBackend("", "secret/{{.Handle}}", 1, 5)
+	require.Error(t, err, "address is required")
+
+	_, err = newVaultBackend("http://example.invalid", "", 1, 5)
+	require.Error(t, err, "path template is required")
+
+	_, err = newVaultBackend("http://example.invalid", "secret/{{.Handle}}", 3, 5)
+	require.Error(t, err, "kv version must be 1 or 2")
+}
@@ -0,0 +1,176 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2018 Datadog, Inc.
+
+package secrets
+
+import (
+	"expvar"
+	"sync"
+	"time"
+
+	"github.com/DataDog/datadog-agent/pkg/util/log"
+)
+
+// secretCacheDefaultTTL is how long a resolved handle stays valid when the
+// backend response didn't include its own 'ttl'.
+var secretCacheDefaultTTL = 5 * time.Minute
+
+// secretCacheRefreshAhead is how far before expiry a cached entry is
+// eligible for background refresh, so config reads are served from cache
+// instead of blocking on a backend round trip.
+var secretCacheRefreshAhead = 30 * time.Second
+
+// secretCacheNegativeTTL is how long a failed resolution is cached for,
+// short enough that a transient backend outage doesn't wedge a handle in
+// its error state for long, but long enough to stop a misbehaving check
+// from hammering the backend every collection cycle. Negative entries are
+// never background-refreshed (see cachingBackend.Resolve), so this doesn't
+// need to stay clear of secretCacheRefreshAhead the way the positive TTL
+// does; it's kept well above it anyway so a shorter refresh window in the
+// future can't silently reintroduce the same hammering.
+var secretCacheNegativeTTL = 5 * time.Minute
+
+var (
+	secretCacheStats        = expvar.NewMap("secretCache")
+	secretCacheHits         expvar.Int
+	secretCacheMisses       expvar.Int
+	secretCacheRefreshFails expvar.Int
+)
+
+func init() {
+	secretCacheStats.Set("Hits", &secretCacheHits)
+	secretCacheStats.Set("Misses", &secretCacheMisses)
+	secretCacheStats.Set("RefreshFailures", &secretCacheRefreshFails)
+}
+
+// cacheEntry is a single memoized handle resolution.
+type cacheEntry struct {
+	val        SecretVal
+	expiresAt  time.Time
+	refreshing bool
+	isNegative bool
+}
+
+// cachingBackend wraps a SecretBackend with a TTL cache so repeated config
+// reloads don't re-resolve every handle against the backend, and
+// transparently refreshes entries shortly before they expire instead of
+// blocking the caller on a cache miss.
+type cachingBackend struct {
+	inner SecretBackend
+
+	mu      sync.Mutex
+	entries map[string]*cacheEntry
+}
+
+// newCachingBackend wraps backend with the package-level TTL cache. It is
+// applied to every backend returned by NewSecretBackend so caching/refresh
+// behavior is identical regardless of transport.
+func newCachingBackend(backend SecretBackend) *cachingBackend {
+	return &cachingBackend{
+		inner:   backend,
+		entries: map[string]*cacheEntry{},
+	}
+}
+
+func (c *cachingBackend) Name() string { return c.inner.Name() }
+
+func (c *cachingBackend) Resolve(handles []string) (map[string]SecretVal, error) {
+	now := time.Now()
+	result := make(map[string]SecretVal, len(handles))
+	var toResolve []string
+
+	c.mu.Lock()
+	for _, handle := range handles {
+		entry, found := c.entries[handle]
+		if !found || now.After(entry.expiresAt) {
+			toResolve = append(toResolve, handle)
+			continue
+		}
+
+		result[handle] = entry.val
+		secretCacheHits.Add(1)
+
+		// Negative entries are deliberately never refreshed ahead of expiry:
+		// doing so would hammer the backend for a handle that just failed,
+		// exactly what the negative TTL exists to prevent. They simply
+		// expire and get resolved like a normal miss on the next lookup.
+		if !entry.isNegative && !entry.refreshing && now.Add(secretCacheRefreshAhead).After(entry.expiresAt) {
+			entry.refreshing = true
+			go c.refresh(handle)
+		}
+	}
+	c.mu.Unlock()
+
+	if len(toResolve) == 0 {
+		return result, nil
+	}
+
+	secretCacheMisses.Add(int64(len(toResolve)))
+	resolved, err := c.inner.Resolve(toResolve)
+	if err != nil {
+		// Surface the error to the caller, but keep the cache hits already
+		// gathered above instead of discarding a whole mixed batch because
+		// one new/expired handle in it failed to resolve.
+		return result, err
+	}
+
+	c.store(resolved, now)
+	for handle, val := range resolved {
+		result[handle] = val
+	}
+	return result, nil
+}
+
+// store writes freshly resolved values into the cache, applying the
+// backend-provided per-handle TTL when set, the negative TTL for errored
+// handles, and the default TTL otherwise.
+func (c *cachingBackend) store(resolved map[string]SecretVal, now time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for handle, val := range resolved {
+		ttl := secretCacheDefaultTTL
+		isNegative := val.Error != ""
+		if isNegative {
+			ttl = secretCacheNegativeTTL
+		} else if val.TTL > 0 {
+			ttl = time.Duration(val.TTL) * time.Second
+		}
+
+		c.entries[handle] = &cacheEntry{
+			val:        val,
+			expiresAt:  now.Add(ttl),
+			isNegative: isNegative,
+		}
+	}
+}
+
+// refresh re-resolves a single handle ahead of its expiry so Resolve keeps
+// serving it from cache without callers observing the backend latency.
+func (c *cachingBackend) refresh(handle string) {
+	resolved, err := c.inner.Resolve([]string{handle})
+	if err != nil {
+		secretCacheRefreshFails.Add(1)
+		log.Warnf("could not refresh cached secret handle '%s': %s", handle, err)
+
+		c.mu.Lock()
+		if entry, found := c.entries[handle]; found {
+			entry.refreshing = false
+		}
+		c.mu.Unlock()
+		return
+	}
+
+	c.store(resolved, time.Now())
+}
+
+// Flush drops every cached entry, forcing the next Resolve call to hit the
+// backend again. See the package-level FlushCache/FlushCacheHandler for how
+// this is exposed to CLI/admin callers.
+func (c *cachingBackend) Flush() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries = map[string]*cacheEntry{}
+}
@@ -0,0 +1,189 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2018 Datadog, Inc.
+
+package secrets
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeBackend is an in-memory SecretBackend used to drive cachingBackend
+// without touching a real transport.
+type fakeBackend struct {
+	mu    sync.Mutex
+	calls int
+	vals  map[string]SecretVal
+	err   error
+}
+
+func newFakeBackend(vals map[string]SecretVal) *fakeBackend {
+	return &fakeBackend{vals: vals}
+}
+
+func (f *fakeBackend) Name() string { return "fake" }
+
+func (f *fakeBackend) Resolve(handles []string) (map[string]SecretVal, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.calls++
+
+	if f.err != nil {
+		return nil, f.err
+	}
+
+	result := make(map[string]SecretVal, len(handles))
+	for _, h := range handles {
+		result[h] = f.vals[h]
+	}
+	return result, nil
+}
+
+func (f *fakeBackend) callCount() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.calls
+}
+
+func withTestTTLs(t *testing.T, defaultTTL, negativeTTL, refreshAhead time.Duration) {
+	origDefault, origNegative, origRefreshAhead := secretCacheDefaultTTL, secretCacheNegativeTTL, secretCacheRefreshAhead
+	secretCacheDefaultTTL, secretCacheNegativeTTL, secretCacheRefreshAhead = defaultTTL, negativeTTL, refreshAhead
+	t.Cleanup(func() {
+		secretCacheDefaultTTL, secretCacheNegativeTTL, secretCacheRefreshAhead = origDefault, origNegative, origRefreshAhead
+	})
+}
+
+func TestCachingBackendCachesHits(t *testing.T) {
+	withTestTTLs(t, time.Minute, time.Minute, 0)
+	backend := newFakeBackend(map[string]SecretVal{"handle1": {Value: "value1"}})
+	cache := newCachingBackend(backend)
+
+	vals, err := cache.Resolve([]string{"handle1"})
+	require.NoError(t, err)
+	assert.Equal(t, "value1", vals["handle1"].Value)
+	assert.Equal(t, 1, backend.callCount())
+
+	vals, err = cache.Resolve([]string{"handle1"})
+	require.NoError(t, err)
+	assert.Equal(t, "value1", vals["handle1"].Value)
+	assert.Equal(t, 1, backend.callCount(), "second lookup should be served from cache")
+}
+
+func TestCachingBackendExpiresEntries(t *testing.T) {
+	withTestTTLs(t, 10*time.Millisecond, time.Minute, 0)
+	backend := newFakeBackend(map[string]SecretVal{"handle1": {Value: "value1"}})
+	cache := newCachingBackend(backend)
+
+	_, err := cache.Resolve([]string{"handle1"})
+	require.NoError(t, err)
+	assert.Equal(t, 1, backend.callCount())
+
+	time.Sleep(20 * time.Millisecond)
+
+	_, err = cache.Resolve([]string{"handle1"})
+	require.NoError(t, err)
+	assert.Equal(t, 2, backend.callCount(), "expired entry should be re-resolved")
+}
+
+func TestCachingBackendNegativeCaching(t *testing.T) {
+	withTestTTLs(t, time.Minute, 20*time.Millisecond, 0)
+	backend := newFakeBackend(map[string]SecretVal{"handle1": {Error: "boom"}})
+	cache := newCachingBackend(backend)
+
+	vals, err := cache.Resolve([]string{"handle1"})
+	require.NoError(t, err)
+	assert.Equal(t, "boom", vals["handle1"].Error)
+	assert.Equal(t, 1, backend.callCount())
+
+	_, err = cache.Resolve([]string{"handle1"})
+	require.NoError(t, err)
+	assert.Equal(t, 1, backend.callCount(), "still within negative TTL, should not re-resolve")
+
+	time.Sleep(30 * time.Millisecond)
+
+	_, err = cache.Resolve([]string{"handle1"})
+	require.NoError(t, err)
+	assert.Equal(t, 2, backend.callCount(), "negative TTL expired, should re-resolve")
+}
+
+func TestCachingBackendNegativeEntriesAreNotBackgroundRefreshed(t *testing.T) {
+	// Negative TTL comfortably outlives refreshAhead: if negative entries
+	// were eligible for background refresh, this alone would trigger one
+	// on the very first read.
+	withTestTTLs(t, time.Minute, time.Minute, time.Minute)
+	backend := newFakeBackend(map[string]SecretVal{"handle1": {Error: "boom"}})
+	cache := newCachingBackend(backend)
+
+	_, err := cache.Resolve([]string{"handle1"})
+	require.NoError(t, err)
+
+	// Give a wrongly-spawned background refresh goroutine a chance to run.
+	time.Sleep(20 * time.Millisecond)
+
+	assert.Equal(t, 1, backend.callCount(), "negative entries must not be background-refreshed")
+}
+
+func TestCachingBackendBackgroundRefresh(t *testing.T) {
+	withTestTTLs(t, 30*time.Millisecond, time.Minute, 20*time.Millisecond)
+	backend := newFakeBackend(map[string]SecretVal{"handle1": {Value: "value1"}})
+	cache := newCachingBackend(backend)
+
+	_, err := cache.Resolve([]string{"handle1"})
+	require.NoError(t, err)
+	assert.Equal(t, 1, backend.callCount())
+
+	// Sleep past the refresh-ahead threshold but before expiry: the read
+	// below should still be served from cache while a refresh happens in
+	// the background.
+	time.Sleep(15 * time.Millisecond)
+	vals, err := cache.Resolve([]string{"handle1"})
+	require.NoError(t, err)
+	assert.Equal(t, "value1", vals["handle1"].Value)
+
+	require.Eventually(t, func() bool {
+		return backend.callCount() >= 2
+	}, time.Second, 5*time.Millisecond, "expected a background refresh call")
+}
+
+func TestCachingBackendPartialFailureKeepsCachedHits(t *testing.T) {
+	withTestTTLs(t, time.Minute, time.Minute, 0)
+	backend := newFakeBackend(map[string]SecretVal{"handle1": {Value: "value1"}})
+	cache := newCachingBackend(backend)
+
+	_, err := cache.Resolve([]string{"handle1"})
+	require.NoError(t, err)
+
+	backend.mu.Lock()
+	backend.err = fmt.Errorf("backend unavailable")
+	backend.mu.Unlock()
+
+	vals, err := cache.Resolve([]string{"handle1", "handle2"})
+	assert.Error(t, err, "resolving the new handle should still surface the backend error")
+	require.NotNil(t, vals, "already-cached handles must not be dropped on a partial failure")
+	assert.Equal(t, "value1", vals["handle1"].Value)
+	_, found := vals["handle2"]
+	assert.False(t, found, "the handle that failed to resolve should not appear in the result")
+}
+
+func TestCachingBackendFlush(t *testing.T) {
+	withTestTTLs(t, time.Minute, time.Minute, 0)
+	backend := newFakeBackend(map[string]SecretVal{"handle1": {Value: "value1"}})
+	cache := newCachingBackend(backend)
+
+	_, err := cache.Resolve([]string{"handle1"})
+	require.NoError(t, err)
+	assert.Equal(t, 1, backend.callCount())
+
+	cache.Flush()
+
+	_, err = cache.Resolve([]string{"handle1"})
+	require.NoError(t, err)
+	assert.Equal(t, 2, backend.callCount(), "flushed entry should be re-resolved")
+}
@@ -212,6 +212,17 @@ func execCommand(inputPayload string) ([]byte, error) {
 		time.Duration(secretBackendTimeout)*time.Second)
 	defer cancel()
 
+	// The datadog-secret-helper service already runs as datadog_secretuser,
+	// so when it's reachable we hand it the subprocess instead of paying
+	// for CreateProcessWithLogonW in this process on every call. If it's
+	// unavailable (not installed, not yet started, ...) we fall back to the
+	// legacy in-process path below.
+	out, err := callSecretHelperWithRetry(ctx, inputPayload)
+	if err == nil {
+		return out, nil
+	}
+	log.Debugf("secret helper unavailable, falling back to legacy subprocess path: %s", err)
+
 	stdin := strings.NewReader(inputPayload)
 	stdout := limitBuffer{
 		buf: &bytes.Buffer{},
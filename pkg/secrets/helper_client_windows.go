@@ -0,0 +1,131 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2018 Datadog, Inc.
+
+// +build windows
+
+package secrets
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/Microsoft/go-winio"
+
+	"github.com/DataDog/datadog-agent/pkg/util/log"
+)
+
+// secretHelperDialTimeout bounds how long a single dial attempt against the
+// helper service is allowed to take.
+var secretHelperDialTimeout = 2 * time.Second
+
+// secretHelperBudgetFraction caps how much of the overall
+// secretBackendTimeout the helper attempt (dial + retries) may spend before
+// giving up, so that when the helper turns out to be unavailable the legacy
+// CreateProcessWithLogonW fallback still has most of the budget left to run
+// the backend command itself, instead of inheriting an already-expired
+// deadline.
+const secretHelperBudgetFraction = 0.3
+
+// callSecretHelper asks the long-lived datadog-secret-helper service to run
+// secretBackendCommand on our behalf over the named pipe, instead of paying
+// for CreateProcessWithLogonW in this process on every call.
+func callSecretHelper(ctx context.Context, inputPayload string) ([]byte, error) {
+	dialCtx, cancel := context.WithTimeout(ctx, secretHelperDialTimeout)
+	defer cancel()
+
+	conn, err := winio.DialPipeContext(dialCtx, secretHelperPipeName)
+	if err != nil {
+		return nil, fmt.Errorf("could not dial secret helper pipe '%s': %s", secretHelperPipeName, err)
+	}
+	defer conn.Close()
+
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	req := helperRequest{
+		Command: secretBackendCommand,
+		Args:    secretBackendArguments,
+		Stdin:   inputPayload,
+	}
+	if err := writeFramed(conn, &req); err != nil {
+		return nil, err
+	}
+
+	var resp helperResponse
+	if err := readFramed(conn, &resp); err != nil {
+		return nil, err
+	}
+
+	if resp.Error != "" {
+		return nil, fmt.Errorf("secret helper failed to run '%s': %s", secretBackendCommand, resp.Error)
+	}
+	if resp.ExitCode != 0 {
+		return nil, fmt.Errorf("'%s' exited with failure status", secretBackendCommand)
+	}
+	if resp.Stderr != "" {
+		log.Debugf("secret backend command '%s' wrote to stderr: %s", secretBackendCommand, resp.Stderr)
+	}
+
+	return []byte(resp.Stdout), nil
+}
+
+// secretHelperMaxRetries and secretHelperRetryBackoff bound the
+// reconnect/backoff behavior when the helper pipe is momentarily
+// unavailable, e.g. right after an agent restart races the helper's own
+// startup.
+var (
+	secretHelperMaxRetries   = 2
+	secretHelperRetryBackoff = 250 * time.Millisecond
+)
+
+// callSecretHelperWithRetry retries callSecretHelper a few times with a
+// short backoff before giving up, so a helper service that hasn't finished
+// starting yet doesn't immediately force every caller onto the legacy
+// CreateProcessWithLogonW path. The whole attempt, retries included, is
+// bounded to secretHelperBudgetFraction of ctx's remaining deadline so a
+// genuinely unavailable helper still leaves the legacy fallback enough real
+// time to run the backend command.
+func callSecretHelperWithRetry(ctx context.Context, inputPayload string) ([]byte, error) {
+	budget := secretHelperDialTimeout
+	if deadline, ok := ctx.Deadline(); ok {
+		if remaining := time.Until(deadline); remaining > 0 {
+			budget = time.Duration(float64(remaining) * secretHelperBudgetFraction)
+		}
+	}
+
+	budgetCtx, cancel := context.WithTimeout(ctx, budget)
+	defer cancel()
+
+	var lastErr error
+	for attempt := 0; attempt <= secretHelperMaxRetries; attempt++ {
+		if budgetCtx.Err() != nil {
+			if lastErr == nil {
+				lastErr = budgetCtx.Err()
+			}
+			break
+		}
+
+		if attempt > 0 {
+			select {
+			case <-time.After(secretHelperRetryBackoff):
+			case <-budgetCtx.Done():
+				break
+			}
+			if budgetCtx.Err() != nil {
+				break
+			}
+		}
+
+		out, err := callSecretHelper(budgetCtx, inputPayload)
+		if err == nil {
+			return out, nil
+		}
+		lastErr = err
+		log.Debugf("could not reach secret helper (attempt %d/%d): %s", attempt+1, secretHelperMaxRetries+1, err)
+	}
+	return nil, lastErr
+}
@@ -0,0 +1,84 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2018 Datadog, Inc.
+
+// +build windows
+
+package secrets
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// secretHelperPipeName is the well-known named pipe the datadog-secret-helper
+// service listens on and the main agent dials to resolve secrets without
+// paying for a CreateProcessWithLogonW call on every handle.
+const secretHelperPipeName = `\\.\pipe\datadog-secrets`
+
+// secretHelperAgentAccount is the account the *main agent process* (the
+// pipe's client) runs as, set at install time from the 'ddagentuser'
+// configuration. It is distinct from `username` (datadog_secretuser), the
+// account the helper itself, and the backend command it spawns, run as.
+// The pipe ACL must grant access to this account, not to datadog_secretuser,
+// since the helper process is the one listening, not the one dialing in.
+var secretHelperAgentAccount = `ddagentuser`
+
+// helperRequest carries everything the helper needs to run
+// secretBackendCommand on the agent's behalf: the command, its arguments,
+// and the stdin payload normally built by execCommand.
+type helperRequest struct {
+	Command string   `json:"command"`
+	Args    []string `json:"args"`
+	Stdin   string   `json:"stdin"`
+}
+
+// helperResponse carries the subprocess' outcome back to the agent, mirroring
+// what execCommand would have observed running the subprocess itself.
+type helperResponse struct {
+	Stdout   string `json:"stdout"`
+	Stderr   string `json:"stderr"`
+	ExitCode int    `json:"exit_code"`
+	Error    string `json:"error,omitempty"`
+}
+
+// writeFramed writes a length-prefixed JSON message: a 4-byte big-endian
+// length followed by the JSON payload. Framing lets the helper and its
+// client tell individual requests/responses apart on the pipe's byte stream.
+func writeFramed(w io.Writer, v interface{}) error {
+	payload, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("could not marshal secret helper message: %s", err)
+	}
+
+	var length [4]byte
+	binary.BigEndian.PutUint32(length[:], uint32(len(payload)))
+	if _, err := w.Write(length[:]); err != nil {
+		return fmt.Errorf("could not write secret helper message length: %s", err)
+	}
+	if _, err := w.Write(payload); err != nil {
+		return fmt.Errorf("could not write secret helper message: %s", err)
+	}
+	return nil
+}
+
+// readFramed reads a single length-prefixed JSON message written by writeFramed.
+func readFramed(r io.Reader, v interface{}) error {
+	var length [4]byte
+	if _, err := io.ReadFull(r, length[:]); err != nil {
+		return fmt.Errorf("could not read secret helper message length: %s", err)
+	}
+
+	payload := make([]byte, binary.BigEndian.Uint32(length[:]))
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return fmt.Errorf("could not read secret helper message: %s", err)
+	}
+
+	if err := json.Unmarshal(payload, v); err != nil {
+		return fmt.Errorf("could not unmarshal secret helper message: %s", err)
+	}
+	return nil
+}
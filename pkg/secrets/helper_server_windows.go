@@ -0,0 +1,140 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2018 Datadog, Inc.
+
+// +build windows
+
+package secrets
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net"
+	"os/exec"
+	"time"
+
+	"github.com/Microsoft/go-winio"
+	"golang.org/x/sys/windows"
+
+	"github.com/DataDog/datadog-agent/pkg/util/log"
+)
+
+// secretHelperPipeSecurityDescriptor builds the SDDL restricting the named
+// pipe to SYSTEM and secretHelperAgentAccount, the account the *main agent
+// process* runs as and therefore the one dialing in as the pipe's client.
+// Granting the ACE to datadog_secretuser (the account the helper itself,
+// and the backend command it runs, execute as) would be wrong: that
+// account never connects to the pipe, it's what's listening on it. Denying
+// everyone else means a lower-privileged local process can't ask the
+// helper to run arbitrary commands as datadog_secretuser.
+//
+// SDDL only accepts a SID string or a well-known two-letter alias (e.g.
+// "SY") in the trustee position, not a raw account name, so the account's
+// SID has to be resolved first.
+func secretHelperPipeSecurityDescriptor() (string, error) {
+	sid, _, _, err := windows.LookupSID("", secretHelperAgentAccount)
+	if err != nil {
+		return "", fmt.Errorf("could not look up SID for '%s': %s", secretHelperAgentAccount, err)
+	}
+	return fmt.Sprintf("D:P(A;;GA;;;SY)(A;;GA;;;%s)", sid.String()), nil
+}
+
+// RunHelperServer starts the datadog-secret-helper named pipe server and
+// blocks until ctx is canceled. It is the entire body of the
+// datadog-secret-helper Windows service: a long-lived process, started once
+// at agent boot and running as datadog_secretuser, that the main agent
+// (running as secretHelperAgentAccount) talks to instead of spawning
+// secretBackendCommand via CreateProcessWithLogonW on every resolution.
+func RunHelperServer(ctx context.Context) error {
+	if err := RecoverPendingSecretUserPassword(); err != nil {
+		log.Warnf("could not recover a pending secret user password: %s", err)
+	}
+
+	sddl, err := secretHelperPipeSecurityDescriptor()
+	if err != nil {
+		return err
+	}
+
+	listener, err := winio.ListenPipe(secretHelperPipeName, &winio.PipeConfig{
+		SecurityDescriptor: sddl,
+		MessageMode:        false,
+	})
+	if err != nil {
+		return fmt.Errorf("could not listen on secret helper pipe '%s': %s", secretHelperPipeName, err)
+	}
+	defer listener.Close()
+
+	go func() {
+		<-ctx.Done()
+		listener.Close()
+	}()
+
+	log.Infof("secret helper listening on %s", secretHelperPipeName)
+
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			log.Warnf("secret helper accept error: %s", err)
+			continue
+		}
+
+		go handleHelperConnection(conn)
+	}
+}
+
+func handleHelperConnection(conn net.Conn) {
+	defer conn.Close()
+
+	var req helperRequest
+	if err := readFramed(conn, &req); err != nil {
+		log.Warnf("secret helper could not read request: %s", err)
+		return
+	}
+
+	resp := runHelperRequest(req)
+	if err := writeFramed(conn, &resp); err != nil {
+		log.Warnf("secret helper could not write response: %s", err)
+	}
+}
+
+// runHelperRequest runs the requested backend command directly: the helper
+// process already runs as datadog_secretuser, so unlike the legacy
+// execCommand path it doesn't need CreateProcessWithLogonW to drop
+// privileges down to that account.
+func runHelperRequest(req helperRequest) helperResponse {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(secretBackendTimeout)*time.Second)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, req.Command, req.Args...)
+	cmd.Stdin = bytes.NewBufferString(req.Stdin)
+
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	err := cmd.Run()
+	resp := helperResponse{
+		Stdout: stdout.String(),
+		Stderr: stderr.String(),
+	}
+
+	if ctx.Err() == context.DeadlineExceeded {
+		resp.Error = fmt.Sprintf("command timeout running '%s'", req.Command)
+		return resp
+	}
+
+	if exitErr, ok := err.(*exec.ExitError); ok {
+		resp.ExitCode = exitErr.ExitCode()
+		return resp
+	}
+
+	if err != nil {
+		resp.Error = err.Error()
+	}
+	return resp
+}
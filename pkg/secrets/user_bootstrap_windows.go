@@ -0,0 +1,405 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2018 Datadog, Inc.
+
+// +build windows
+
+package secrets
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"syscall"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+	"golang.org/x/sys/windows/registry"
+	"golang.org/x/sys/windows/svc/mgr"
+
+	"github.com/DataDog/datadog-agent/pkg/util/log"
+)
+
+var (
+	netapi32             = syscall.NewLazyDLL("netapi32.dll")
+	procNetUserAdd       = netapi32.NewProc("NetUserAdd")
+	procNetUserSetInfo   = netapi32.NewProc("NetUserSetInfo")
+	procNetApiBufferFree = netapi32.NewProc("NetApiBufferFree")
+
+	procLsaOpenPolicy       = advapi32.NewProc("LsaOpenPolicy")
+	procLsaAddAccountRights = advapi32.NewProc("LsaAddAccountRights")
+	procLsaClose            = advapi32.NewProc("LsaClose")
+)
+
+// secretHelperServiceName is the Windows service name registered by
+// cmd/secrethelper, used to restart the helper as part of a password
+// rotation.
+const secretHelperServiceName = "datadog-secret-helper"
+
+const secretUserPasswordBytes = 32
+
+// registryPendingSuffix marks the "next" password written before the
+// account's password is actually changed, so a crash mid-rotation leaves a
+// recoverable trail instead of silently losing the new password. A pending
+// value left behind means the crash happened after netUserSetPassword
+// already took effect but before writeRegistryPassword committed it as the
+// primary value; RecoverPendingSecretUserPassword finishes that commit.
+const registryPendingSuffix = "_pending"
+
+// UserBootstrapConfig configures BootstrapSecretUser. Username defaults to
+// the well-known datadog_secretuser account used everywhere else in this
+// package when left empty.
+type UserBootstrapConfig struct {
+	Username string
+}
+
+// BootstrapSecretUser creates the low-privilege local account the secret
+// helper runs as, denies it interactive and RDP logon, and stores a
+// freshly generated password for it in the registry. It replaces the
+// implicit assumption that an installer did all of this out-of-band,
+// letting install-less/automated deployments set the account up themselves.
+func BootstrapSecretUser(cfg UserBootstrapConfig) error {
+	user := cfg.Username
+	if user == "" {
+		user = username
+	}
+
+	password, err := generateSecretUserPassword()
+	if err != nil {
+		return err
+	}
+
+	if err := netUserAdd(user, password); err != nil {
+		return err
+	}
+
+	if err := denyInteractiveLogon(user); err != nil {
+		return fmt.Errorf("created %s but could not restrict its logon rights: %s", user, err)
+	}
+
+	if err := writeRegistryPassword(user, password); err != nil {
+		return fmt.Errorf("created %s but could not persist its password: %s", user, err)
+	}
+
+	log.Infof("bootstrapped secret user %s", user)
+	return nil
+}
+
+// RotateSecretUserPassword generates a new password for the secret user,
+// double-buffering it through the registry so the rotation survives a crash
+// partway through, then restarts the secret helper service so it picks up
+// the new credential without the main agent observing any downtime beyond
+// the helper's own restart.
+func RotateSecretUserPassword() error {
+	newPassword, err := generateSecretUserPassword()
+	if err != nil {
+		return err
+	}
+
+	// Write the new password before touching the account: if we crash here,
+	// the account's real password (in the non-pending registry value) is
+	// still valid and nothing has been lost.
+	if err := writeRegistryValue(username+registryPendingSuffix, newPassword); err != nil {
+		return fmt.Errorf("could not stage pending secret user password: %s", err)
+	}
+
+	if err := netUserSetPassword(username, newPassword); err != nil {
+		return fmt.Errorf("could not set new secret user password: %s", err)
+	}
+
+	if err := restartSecretHelperService(newPassword); err != nil {
+		return fmt.Errorf("secret user password changed but could not restart %s, "+
+			"secret resolution will fail until it is restarted: %s", secretHelperServiceName, err)
+	}
+
+	if err := writeRegistryPassword(username, newPassword); err != nil {
+		return fmt.Errorf("secret user password changed but could not persist it: %s", err)
+	}
+
+	return deleteRegistryValue(username + registryPendingSuffix)
+}
+
+// RecoverPendingSecretUserPassword finishes a rotation that crashed between
+// netUserSetPassword and writeRegistryPassword in RotateSecretUserPassword.
+// By the time a pending value exists, the OS account (and the helper
+// service's stored logon credential) already have the new password, so
+// recovery is just promoting the pending value to the primary one. It is a
+// no-op if no rotation was interrupted, so callers can run it
+// unconditionally; RunHelperServer does so on every startup.
+func RecoverPendingSecretUserPassword() error {
+	k, err := registry.OpenKey(registry.LOCAL_MACHINE, passwordRegistryPath, registry.QUERY_VALUE)
+	if err != nil {
+		if err == registry.ErrNotExist {
+			return nil
+		}
+		return fmt.Errorf("could not open '%s' to check for a pending secret user password: %s", passwordRegistryPath, err)
+	}
+	pending, _, err := k.GetStringValue(username + registryPendingSuffix)
+	k.Close()
+	if err == registry.ErrNotExist {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("could not read pending secret user password: %s", err)
+	}
+
+	log.Warnf("found a pending secret user password left by an interrupted rotation, committing it")
+	if err := writeRegistryPassword(username, pending); err != nil {
+		return fmt.Errorf("could not commit pending secret user password: %s", err)
+	}
+	return deleteRegistryValue(username + registryPendingSuffix)
+}
+
+func generateSecretUserPassword() (string, error) {
+	buf := make([]byte, secretUserPasswordBytes)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("could not generate secret user password: %s", err)
+	}
+	return base64.RawStdEncoding.EncodeToString(buf), nil
+}
+
+// netUserAdd creates a local user account with USER_PRIV_USER rights and a
+// password that never expires, mirroring what the installer previously did
+// out-of-band via NetUserAdd.
+func netUserAdd(user, password string) error {
+	type userInfo1 struct {
+		Name        *uint16
+		Password    *uint16
+		PasswordAge uint32
+		Priv        uint32
+		HomeDir     *uint16
+		Comment     *uint16
+		Flags       uint32
+		ScriptPath  *uint16
+	}
+
+	const (
+		userPrivUser       = 1
+		ufDontExpirePasswd = 0x10000
+	)
+
+	namePtr, err := syscall.UTF16PtrFromString(user)
+	if err != nil {
+		return err
+	}
+	passwordPtr, err := syscall.UTF16PtrFromString(password)
+	if err != nil {
+		return err
+	}
+	commentPtr, _ := syscall.UTF16PtrFromString("Datadog Agent secret backend account")
+
+	info := userInfo1{
+		Name:     namePtr,
+		Password: passwordPtr,
+		Priv:     userPrivUser,
+		Comment:  commentPtr,
+		Flags:    ufDontExpirePasswd,
+	}
+
+	var parmErr uint32
+	ret, _, _ := procNetUserAdd.Call(
+		0, // local server
+		1, // level 1
+		uintptr(unsafe.Pointer(&info)),
+		uintptr(unsafe.Pointer(&parmErr)),
+	)
+	if ret != 0 {
+		return fmt.Errorf("NetUserAdd failed for '%s' with code %d (param error %d)", user, ret, parmErr)
+	}
+	return nil
+}
+
+// netUserSetPassword changes an existing local account's password via
+// NetUserSetInfo level 1003, which only touches the password field.
+func netUserSetPassword(user, password string) error {
+	type userInfo1003 struct {
+		Password *uint16
+	}
+
+	passwordPtr, err := syscall.UTF16PtrFromString(password)
+	if err != nil {
+		return err
+	}
+	info := userInfo1003{Password: passwordPtr}
+
+	var parmErr uint32
+	ret, _, _ := procNetUserSetInfo.Call(
+		0,
+		uintptr(unsafe.Pointer(mustUTF16Ptr(user))),
+		1003,
+		uintptr(unsafe.Pointer(&info)),
+		uintptr(unsafe.Pointer(&parmErr)),
+	)
+	if ret != 0 {
+		return fmt.Errorf("NetUserSetInfo failed for '%s' with code %d (param error %d)", user, ret, parmErr)
+	}
+	return nil
+}
+
+func mustUTF16Ptr(s string) *uint16 {
+	p, _ := syscall.UTF16PtrFromString(s)
+	return p
+}
+
+// denyInteractiveLogon grants the account the "deny log on locally" and
+// "deny log on through Remote Desktop Services" LSA privileges, so a
+// process compromise of whatever runs as this account can't be used to open
+// an interactive session with it.
+func denyInteractiveLogon(user string) error {
+	sid, _, _, err := windows.LookupSID("", user)
+	if err != nil {
+		return fmt.Errorf("could not look up SID for '%s': %s", user, err)
+	}
+
+	var policyHandle windows.Handle
+	objectAttrs := struct {
+		Length                   uint32
+		RootDirectory            windows.Handle
+		ObjectName               uintptr
+		Attributes               uint32
+		SecurityDescriptor       uintptr
+		SecurityQualityOfService uintptr
+	}{}
+
+	const policyAllAccess = 0x000F0FFF
+	ret, _, _ := procLsaOpenPolicy.Call(
+		0,
+		uintptr(unsafe.Pointer(&objectAttrs)),
+		policyAllAccess,
+		uintptr(unsafe.Pointer(&policyHandle)),
+	)
+	if ret != 0 {
+		return fmt.Errorf("LsaOpenPolicy failed with NTSTATUS 0x%x", ret)
+	}
+	defer procLsaClose.Call(uintptr(policyHandle))
+
+	rights := []string{"SeDenyInteractiveLogonRight", "SeDenyRemoteInteractiveLogonRight"}
+	for _, right := range rights {
+		rightUTF16, err := syscall.UTF16PtrFromString(right)
+		if err != nil {
+			return err
+		}
+		lsaRight := struct {
+			Length uint16
+			Buffer *uint16
+		}{
+			Length: uint16(len(right) * 2),
+			Buffer: rightUTF16,
+		}
+
+		ret, _, _ := procLsaAddAccountRights.Call(
+			uintptr(policyHandle),
+			uintptr(unsafe.Pointer(sid)),
+			uintptr(unsafe.Pointer(&lsaRight)),
+			1,
+		)
+		if ret != 0 {
+			return fmt.Errorf("LsaAddAccountRights(%s) failed with NTSTATUS 0x%x", right, ret)
+		}
+	}
+	return nil
+}
+
+// writeRegistryPassword writes password under valueName, restricted so
+// only SYSTEM and local Administrators can read it back, mirroring the
+// permissions an installer would have set on the key out-of-band.
+func writeRegistryPassword(valueName, password string) error {
+	if err := restrictRegistryKeyACL(); err != nil {
+		return err
+	}
+	return writeRegistryValue(valueName, password)
+}
+
+func writeRegistryValue(valueName, value string) error {
+	k, _, err := registry.CreateKey(registry.LOCAL_MACHINE, passwordRegistryPath, registry.SET_VALUE)
+	if err != nil {
+		return fmt.Errorf("could not open '%s' for writing: %s", passwordRegistryPath, err)
+	}
+	defer k.Close()
+
+	if err := k.SetStringValue(valueName, value); err != nil {
+		return fmt.Errorf("could not write '%s' registry value: %s", valueName, err)
+	}
+	return nil
+}
+
+func deleteRegistryValue(valueName string) error {
+	k, err := registry.OpenKey(registry.LOCAL_MACHINE, passwordRegistryPath, registry.SET_VALUE)
+	if err != nil {
+		return fmt.Errorf("could not open '%s' for deletion: %s", passwordRegistryPath, err)
+	}
+	defer k.Close()
+
+	if err := k.DeleteValue(valueName); err != nil && err != registry.ErrNotExist {
+		return fmt.Errorf("could not delete '%s' registry value: %s", valueName, err)
+	}
+	return nil
+}
+
+// restrictRegistryKeyACL locks SOFTWARE\Datadog\Datadog Agent\secrets down
+// to SYSTEM and the built-in Administrators group, denying every other
+// principal (including the secret user itself) read access.
+func restrictRegistryKeyACL() error {
+	const sddl = "D:P(A;;KA;;;SY)(A;;KA;;;BA)"
+
+	sd, err := windows.SecurityDescriptorFromString(sddl)
+	if err != nil {
+		return fmt.Errorf("could not build security descriptor: %s", err)
+	}
+
+	dacl, _, err := sd.DACL()
+	if err != nil {
+		return fmt.Errorf("could not read DACL from security descriptor: %s", err)
+	}
+
+	keyPath := `MACHINE\` + passwordRegistryPath
+	return windows.SetNamedSecurityInfo(
+		keyPath,
+		windows.SE_REGISTRY_KEY,
+		windows.DACL_SECURITY_INFORMATION|windows.PROTECTED_DACL_SECURITY_INFORMATION,
+		nil, nil, dacl, nil,
+	)
+}
+
+// restartSecretHelperService updates the datadog-secret-helper service's
+// stored "Log On As" credential to newPassword, then stops and starts it via
+// the service control manager so the rotation takes effect immediately
+// instead of waiting for the next reboot.
+//
+// The SCM keeps its own copy of the service account's password, set at
+// install time, and uses that copy (not the account's live password) to log
+// the service on at start. netUserSetPassword changing the OS account's
+// password does not update that copy, so it has to be refreshed explicitly
+// or the next start fails with a logon failure.
+func restartSecretHelperService(newPassword string) error {
+	m, err := mgr.Connect()
+	if err != nil {
+		return fmt.Errorf("could not connect to service control manager: %s", err)
+	}
+	defer m.Disconnect()
+
+	svc, err := m.OpenService(secretHelperServiceName)
+	if err != nil {
+		return fmt.Errorf("could not open service '%s': %s", secretHelperServiceName, err)
+	}
+	defer svc.Close()
+
+	cfg, err := svc.Config()
+	if err != nil {
+		return fmt.Errorf("could not read config for service '%s': %s", secretHelperServiceName, err)
+	}
+	cfg.Password = newPassword
+	if err := svc.UpdateConfig(cfg); err != nil {
+		return fmt.Errorf("could not update logon password for service '%s': %s", secretHelperServiceName, err)
+	}
+
+	if _, err := svc.Control(windows.SERVICE_CONTROL_STOP); err != nil {
+		return fmt.Errorf("could not stop service '%s': %s", secretHelperServiceName, err)
+	}
+
+	if err := svc.Start(); err != nil {
+		return fmt.Errorf("could not start service '%s': %s", secretHelperServiceName, err)
+	}
+	return nil
+}